@@ -1,16 +1,27 @@
 package certauth
 
 import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
 	"fmt"
+	"math/big"
+	"net"
 	"os"
-	"os/exec"
 	"path/filepath"
-
-	"github.com/alecthomas/template"
+	"time"
 )
 
 const defaultServiceIP = "10.3.0.1"
 
+const (
+	rootCertValidity = 10 * 365 * 24 * time.Hour
+	leafCertValidity = 365 * 24 * time.Hour
+	rsaKeyBits       = 2048
+)
+
 // CA is a certificate authority.
 type CA struct {
 	// Dir is the path where certs and keys will be generated
@@ -21,6 +32,9 @@ type CA struct {
 
 	// Verbose mode displays command output
 	verbose bool
+
+	rootCert *x509.Certificate
+	rootKey  *rsa.PrivateKey
 }
 
 // New creates a CA.
@@ -48,132 +62,171 @@ func IsVerbose() func(*CA) {
 // CreateRoot creates a certificate authority that will be used to sign
 // additional certificates.
 func (ca *CA) CreateRoot() error {
-	err := ca.openssl("genrsa", "-out", "ca-key.pem", "2048")
+	key, err := rsa.GenerateKey(rand.Reader, rsaKeyBits)
 	if err != nil {
-		return err
+		return fmt.Errorf("could not generate root key: %v", err)
 	}
 
-	return ca.openssl("req", "-x509", "-new", "-nodes", "-key",
-		"ca-key.pem", "-days", "10000", "-out", "ca.pem", "-subj",
-		"/CN=kube-ca")
-}
-
-// CreateAPIServerKeyPair creates a keypair for the api server.
-func (ca *CA) CreateAPIServerKeyPair(masterIP string) error {
-	p := filepath.Join(ca.dir, "openssl.cnf")
-	f, err := os.Create(p)
+	serial, err := newSerial()
 	if err != nil {
 		return err
 	}
 
-	t, err := template.New("openssl config").Parse(opensslConfig)
-	if err != nil {
-		return err
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: "kube-ca"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(rootCertValidity),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
 	}
 
-	args := map[string]interface{}{
-		"ServiceIP":  ca.serviceIP,
-		"MasterHost": masterIP,
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return fmt.Errorf("could not create root certificate: %v", err)
 	}
 
-	err = t.Execute(f, args)
+	cert, err := x509.ParseCertificate(der)
 	if err != nil {
-		return err
+		return fmt.Errorf("could not parse root certificate: %v", err)
 	}
 
-	err = ca.openssl("genrsa", "-out", "apiserver-key.pem", "2048")
-	if err != nil {
+	if err := ca.writeKeyPair("ca", key, der); err != nil {
 		return err
 	}
 
-	err = ca.openssl("req", "-new", "-key", "apiserver-key.pem", "-out", "apiserver.csr",
-		"-subj", "/CN=kube-apiserver", "-config", "openssl.cnf")
-	if err != nil {
-		return err
+	ca.rootCert = cert
+	ca.rootKey = key
+
+	return nil
+}
+
+// CreateAPIServerKeyPair creates a keypair for the api server. hosts may
+// mix IP addresses (e.g. master IPs) and DNS names (e.g. a load balancer
+// hostname fronting a multi-master control plane); each is placed in the
+// appropriate SAN field.
+func (ca *CA) CreateAPIServerKeyPair(hosts ...string) error {
+	dnsNames := []string{"kubernetes", "kubernetes.default"}
+	ips := ipAddresses(ca.serviceIP)
+
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			ips = append(ips, ip)
+		} else {
+			dnsNames = append(dnsNames, h)
+		}
+	}
+
+	template := &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "kube-apiserver"},
+		DNSNames:    dnsNames,
+		IPAddresses: ips,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
 	}
 
-	return ca.openssl("x509", "-req", "-in", "apiserver.csr", "-CA", "ca.pem",
-		"-CAkey", "ca-key.pem", "-CAcreateserial", "-out", "apiserver.pem",
-		"-days", "365", "-extensions", "v3_req", "-extfile", "openssl.cnf")
+	return ca.signAndWrite("apiserver", template)
 }
 
 // CreateWorkerKeyPair creates key pairs for workers.
 func (ca *CA) CreateWorkerKeyPair(workerFQDN, workerIP string) error {
-	p := filepath.Join(ca.dir, "worker-openssl.cnf")
-	f, err := os.Create(p)
-	if err != nil {
-		return err
+	template := &x509.Certificate{
+		Subject:     pkix.Name{CommonName: workerFQDN},
+		IPAddresses: ipAddresses(workerIP),
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
 	}
 
-	t, err := template.New("worker openssl config").Parse(workerOpensslConfig)
-	if err != nil {
-		return err
+	return ca.signAndWrite(fmt.Sprintf("%s-worker", workerFQDN), template)
+}
+
+// CreateAdminKeyPair creates an admin key pair
+func (ca *CA) CreateAdminKeyPair() error {
+	template := &x509.Certificate{
+		Subject:     pkix.Name{CommonName: "kube-admin"},
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
 	}
 
-	args := map[string]interface{}{
-		"WorkerIP": workerIP,
+	return ca.signAndWrite("admin", template)
+}
+
+// signAndWrite generates a key pair, signs the certificate template with the
+// root CA, and writes the resulting key and certificate to ca.dir using
+// name-key.pem and name.pem as file names.
+func (ca *CA) signAndWrite(name string, template *x509.Certificate) error {
+	if ca.rootCert == nil || ca.rootKey == nil {
+		return fmt.Errorf("root ca has not been created")
 	}
 
-	err = t.Execute(f, args)
+	key, err := rsa.GenerateKey(rand.Reader, rsaKeyBits)
 	if err != nil {
-		return err
+		return fmt.Errorf("could not generate key for %s: %v", name, err)
 	}
 
-	key := fmt.Sprintf("%s-worker-key.pem", workerFQDN)
-	csr := fmt.Sprintf("%s-worker.csr", workerFQDN)
-	crt := fmt.Sprintf("%s-worker.pem", workerFQDN)
-
-	err = ca.openssl("genrsa", "-out", key, "2048")
+	serial, err := newSerial()
 	if err != nil {
 		return err
 	}
 
-	err = ca.openssl("req", "-new", "-key", key,
-		"-out", csr, "-subj", "/CN=${WORKER_FQDN}",
-		"-config", "worker-openssl.cnf")
+	template.SerialNumber = serial
+	template.NotBefore = time.Now()
+	template.NotAfter = time.Now().Add(leafCertValidity)
+	template.KeyUsage = x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.rootCert, &key.PublicKey, ca.rootKey)
 	if err != nil {
-		return err
+		return fmt.Errorf("could not sign certificate for %s: %v", name, err)
+	}
+
+	if ca.verbose {
+		fmt.Printf("signed %s, valid until %s\n", name, template.NotAfter)
 	}
 
-	return ca.openssl("x509", "-req", "-in", csr,
-		"-CA", "ca.pem", "-CAkey", "ca-key.pem", "-CAcreateserial",
-		"-out", crt, "-days", "365",
-		"-extensions", "v3_req", "-extfile", "worker-openssl.cnf")
+	return ca.writeKeyPair(name, key, der)
 }
 
-// CreateAdminKeyPair creates an admin key pair
-func (ca *CA) CreateAdminKeyPair() error {
-	err := ca.openssl("genrsa", "-out", "admin-key.pem", "2048")
-	if err != nil {
-		return err
+// writeKeyPair writes a PEM-encoded RSA private key and certificate to
+// ca.dir as name-key.pem and name.pem.
+func (ca *CA) writeKeyPair(name string, key *rsa.PrivateKey, der []byte) error {
+	keyPath := filepath.Join(ca.dir, fmt.Sprintf("%s-key.pem", name))
+	if err := writePEM(keyPath, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(key)); err != nil {
+		return fmt.Errorf("could not write %s: %v", keyPath, err)
 	}
 
-	err = ca.openssl("req", "-new", "-key", "admin-key.pem",
-		"-out", "admin.csr", "-subj", "/CN=kube-admin")
-	if err != nil {
-		return err
+	certPath := filepath.Join(ca.dir, fmt.Sprintf("%s.pem", name))
+	if err := writePEM(certPath, "CERTIFICATE", der); err != nil {
+		return fmt.Errorf("could not write %s: %v", certPath, err)
 	}
 
-	return ca.openssl("x509", "-req", "-in", "admin.csr", "-CA", "ca.pem",
-		"-CAkey", "ca-key.pem", "-CAcreateserial",
-		"-out", "admin.pem", "-days", "365")
+	return nil
 }
 
-func (ca *CA) openssl(args ...string) error {
-	cmd := exec.Command("openssl", args...)
-	cmd.Dir = ca.dir
+func writePEM(path, blockType string, bytes []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
 
-	out, err := cmd.CombinedOutput()
+	return pem.Encode(f, &pem.Block{Type: blockType, Bytes: bytes})
+}
 
-	if ca.verbose {
-		fmt.Println(string(out))
+func newSerial() (*big.Int, error) {
+	limit := new(big.Int).Lsh(big.NewInt(1), 128)
+	serial, err := rand.Int(rand.Reader, limit)
+	if err != nil {
+		return nil, fmt.Errorf("could not generate certificate serial number: %v", err)
 	}
 
-	return err
+	return serial, nil
 }
 
-//go:generate embed file -var opensslConfig -source openssl.cnf
-var opensslConfig = "[req]\nreq_extensions = v3_req\ndistinguished_name = req_distinguished_name\n[req_distinguished_name]\n[ v3_req ]\nbasicConstraints = CA:FALSE\nkeyUsage = nonRepudiation, digitalSignature, keyEncipherment\nsubjectAltName = @alt_names\n[alt_names]\nDNS.1 = kubernetes\nDNS.2 = kubernetes.default\nIP.1 = {{.ServiceIP}}\nIP.2 = {{.MasterHost}}\n"
+func ipAddresses(addrs ...string) []net.IP {
+	var ips []net.IP
+	for _, a := range addrs {
+		if ip := net.ParseIP(a); ip != nil {
+			ips = append(ips, ip)
+		}
+	}
 
-//go:generate embed file -var workerOpensslConfig -source worker-openssl.cnf
-var workerOpensslConfig = "[req]\nreq_extensions = v3_req\ndistinguished_name = req_distinguished_name\n[req_distinguished_name]\n[v3_req]\nbasicConstraints = CA:FALSE\nkeyUsage = nonRepudiation, digitalSignature, keyEncipherment\nsubjectAltName = @alt_names\n[alt_names]\nIP.1 = {{.WorkerIP}}\n"
+	return ips
+}
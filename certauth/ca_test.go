@@ -0,0 +1,177 @@
+package certauth
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"io/ioutil"
+	"net"
+	"path/filepath"
+	"testing"
+)
+
+func testCA(t *testing.T) (*CA, string) {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "certauth")
+	if err != nil {
+		t.Fatalf("create temp dir: %v", err)
+	}
+
+	ca, err := New(dir)
+	if err != nil {
+		t.Fatalf("create ca: %v", err)
+	}
+
+	if err := ca.CreateRoot(); err != nil {
+		t.Fatalf("create root: %v", err)
+	}
+
+	return ca, dir
+}
+
+func readCert(t *testing.T, dir, name string) *x509.Certificate {
+	t.Helper()
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		t.Fatalf("read %s: %v", name, err)
+	}
+
+	block, _ := pem.Decode(b)
+	if block == nil {
+		t.Fatalf("decode %s: no pem block found", name)
+	}
+
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		t.Fatalf("parse %s: %v", name, err)
+	}
+
+	return cert
+}
+
+func TestCreateRoot(t *testing.T) {
+	_, dir := testCA(t)
+
+	cert := readCert(t, dir, "ca.pem")
+
+	if !cert.IsCA {
+		t.Error("root cert is not marked as a CA")
+	}
+	if cert.KeyUsage&x509.KeyUsageCertSign == 0 {
+		t.Error("root cert is missing KeyUsageCertSign")
+	}
+}
+
+func TestCreateAPIServerKeyPair(t *testing.T) {
+	ca, dir := testCA(t)
+
+	if err := ca.CreateAPIServerKeyPair("172.16.0.10", "master.example.com"); err != nil {
+		t.Fatalf("create api server key pair: %v", err)
+	}
+
+	root := readCert(t, dir, "ca.pem")
+	cert := readCert(t, dir, "apiserver.pem")
+
+	if err := cert.CheckSignatureFrom(root); err != nil {
+		t.Errorf("apiserver cert is not signed by the root: %v", err)
+	}
+
+	wantDNS := map[string]bool{"kubernetes": true, "kubernetes.default": true, "master.example.com": true}
+	for _, d := range cert.DNSNames {
+		delete(wantDNS, d)
+	}
+	if len(wantDNS) != 0 {
+		t.Errorf("apiserver cert is missing DNS SANs: %v", wantDNS)
+	}
+
+	wantIP := net.ParseIP("172.16.0.10")
+	found := false
+	for _, ip := range cert.IPAddresses {
+		if ip.Equal(wantIP) {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("apiserver cert is missing IP SAN %s", wantIP)
+	}
+
+	if !hasExtKeyUsage(cert, x509.ExtKeyUsageServerAuth) {
+		t.Error("apiserver cert is missing ExtKeyUsageServerAuth")
+	}
+}
+
+func TestCreateWorkerKeyPair(t *testing.T) {
+	ca, dir := testCA(t)
+
+	if err := ca.CreateWorkerKeyPair("worker-0", "10.1.2.3"); err != nil {
+		t.Fatalf("create worker key pair: %v", err)
+	}
+
+	root := readCert(t, dir, "ca.pem")
+	cert := readCert(t, dir, "worker-0-worker.pem")
+
+	if err := cert.CheckSignatureFrom(root); err != nil {
+		t.Errorf("worker cert is not signed by the root: %v", err)
+	}
+
+	if cert.Subject.CommonName != "worker-0" {
+		t.Errorf("worker cert CommonName = %q, want %q", cert.Subject.CommonName, "worker-0")
+	}
+
+	wantIP := net.ParseIP("10.1.2.3")
+	found := false
+	for _, ip := range cert.IPAddresses {
+		if ip.Equal(wantIP) {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("worker cert is missing IP SAN %s", wantIP)
+	}
+}
+
+func TestCreateAdminKeyPair(t *testing.T) {
+	ca, dir := testCA(t)
+
+	if err := ca.CreateAdminKeyPair(); err != nil {
+		t.Fatalf("create admin key pair: %v", err)
+	}
+
+	root := readCert(t, dir, "ca.pem")
+	cert := readCert(t, dir, "admin.pem")
+
+	if err := cert.CheckSignatureFrom(root); err != nil {
+		t.Errorf("admin cert is not signed by the root: %v", err)
+	}
+
+	if !hasExtKeyUsage(cert, x509.ExtKeyUsageClientAuth) {
+		t.Error("admin cert is missing ExtKeyUsageClientAuth")
+	}
+}
+
+func TestCreateAdminKeyPairWithoutRoot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "certauth")
+	if err != nil {
+		t.Fatalf("create temp dir: %v", err)
+	}
+
+	ca, err := New(dir)
+	if err != nil {
+		t.Fatalf("create ca: %v", err)
+	}
+
+	if err := ca.CreateAdminKeyPair(); err == nil {
+		t.Error("expected an error creating a key pair before the root ca exists")
+	}
+}
+
+func hasExtKeyUsage(cert *x509.Certificate, want x509.ExtKeyUsage) bool {
+	for _, u := range cert.ExtKeyUsage {
+		if u == want {
+			return true
+		}
+	}
+
+	return false
+}
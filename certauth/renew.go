@@ -0,0 +1,135 @@
+package certauth
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// LoadRoot reads an existing ca.pem/ca-key.pem back into memory so that
+// Renew* methods can sign under a CA created in a previous run.
+func (ca *CA) LoadRoot() error {
+	certPEM, err := ioutil.ReadFile(filepath.Join(ca.dir, "ca.pem"))
+	if err != nil {
+		return fmt.Errorf("could not read ca.pem: %v", err)
+	}
+
+	certBlock, _ := pem.Decode(certPEM)
+	if certBlock == nil {
+		return fmt.Errorf("could not decode ca.pem")
+	}
+
+	cert, err := x509.ParseCertificate(certBlock.Bytes)
+	if err != nil {
+		return fmt.Errorf("could not parse ca.pem: %v", err)
+	}
+
+	keyPEM, err := ioutil.ReadFile(filepath.Join(ca.dir, "ca-key.pem"))
+	if err != nil {
+		return fmt.Errorf("could not read ca-key.pem: %v", err)
+	}
+
+	keyBlock, _ := pem.Decode(keyPEM)
+	if keyBlock == nil {
+		return fmt.Errorf("could not decode ca-key.pem")
+	}
+
+	key, err := x509.ParsePKCS1PrivateKey(keyBlock.Bytes)
+	if err != nil {
+		return fmt.Errorf("could not parse ca-key.pem: %v", err)
+	}
+
+	ca.rootCert = cert
+	ca.rootKey = key
+
+	return nil
+}
+
+// RenewAPIServerKeyPair archives the current apiserver cert and signs a
+// fresh key pair under the existing root.
+func (ca *CA) RenewAPIServerKeyPair(hosts ...string) error {
+	if err := ca.archiveCert("apiserver"); err != nil {
+		return err
+	}
+
+	return ca.CreateAPIServerKeyPair(hosts...)
+}
+
+// RenewWorkerKeyPair archives the current worker cert and signs a fresh key
+// pair under the existing root.
+func (ca *CA) RenewWorkerKeyPair(fqdn, ip string) error {
+	if err := ca.archiveCert(fmt.Sprintf("%s-worker", fqdn)); err != nil {
+		return err
+	}
+
+	return ca.CreateWorkerKeyPair(fqdn, ip)
+}
+
+// RenewAdminKeyPair archives the current admin cert and signs a fresh key
+// pair under the existing root.
+func (ca *CA) RenewAdminKeyPair() error {
+	if err := ca.archiveCert("admin"); err != nil {
+		return err
+	}
+
+	return ca.CreateAdminKeyPair()
+}
+
+// archiveCert renames name.pem to name.pem.<unix-timestamp>.bak so a Renew
+// call never clobbers the previous certificate outright. It is a no-op if
+// no certificate with that name exists yet.
+func (ca *CA) archiveCert(name string) error {
+	certPath := filepath.Join(ca.dir, fmt.Sprintf("%s.pem", name))
+
+	if _, err := ioutil.ReadFile(certPath); err != nil {
+		return nil
+	}
+
+	backupPath := fmt.Sprintf("%s.%d.bak", certPath, time.Now().Unix())
+	return os.Rename(certPath, backupPath)
+}
+
+// Expiring returns the names of certificates in ca.dir whose NotAfter falls
+// within the given window, so operators can script renewals from cron.
+func (ca *CA) Expiring(within time.Duration) ([]string, error) {
+	entries, err := ioutil.ReadDir(ca.dir)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(within)
+
+	var expiring []string
+	for _, e := range entries {
+		name := e.Name()
+		if !strings.HasSuffix(name, ".pem") || strings.HasSuffix(name, "-key.pem") {
+			continue
+		}
+
+		b, err := ioutil.ReadFile(filepath.Join(ca.dir, name))
+		if err != nil {
+			return nil, err
+		}
+
+		block, _ := pem.Decode(b)
+		if block == nil {
+			continue
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			continue
+		}
+
+		if cert.NotAfter.Before(deadline) {
+			expiring = append(expiring, strings.TrimSuffix(name, ".pem"))
+		}
+	}
+
+	return expiring, nil
+}
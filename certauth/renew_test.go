@@ -0,0 +1,98 @@
+package certauth
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadRootAndRenewAPIServerKeyPair(t *testing.T) {
+	_, dir := testCA(t)
+
+	loaded, err := New(dir)
+	if err != nil {
+		t.Fatalf("create ca: %v", err)
+	}
+	if err := loaded.LoadRoot(); err != nil {
+		t.Fatalf("load root: %v", err)
+	}
+
+	if err := loaded.CreateAPIServerKeyPair("127.0.0.1"); err != nil {
+		t.Fatalf("create api server key pair: %v", err)
+	}
+
+	first := readCert(t, dir, "apiserver.pem")
+
+	if err := loaded.RenewAPIServerKeyPair("127.0.0.1"); err != nil {
+		t.Fatalf("renew api server key pair: %v", err)
+	}
+
+	root := readCert(t, dir, "ca.pem")
+	second := readCert(t, dir, "apiserver.pem")
+
+	if first.SerialNumber.Cmp(second.SerialNumber) == 0 {
+		t.Error("renewed cert has the same serial number as the original")
+	}
+	if err := second.CheckSignatureFrom(root); err != nil {
+		t.Errorf("renewed cert is not signed by the root: %v", err)
+	}
+}
+
+func TestRenewArchivesPreviousCert(t *testing.T) {
+	ca, dir := testCA(t)
+
+	if err := ca.CreateAdminKeyPair(); err != nil {
+		t.Fatalf("create admin key pair: %v", err)
+	}
+
+	if err := ca.RenewAdminKeyPair(); err != nil {
+		t.Fatalf("renew admin key pair: %v", err)
+	}
+
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatalf("read dir: %v", err)
+	}
+
+	found := false
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), "admin.pem.") && strings.HasSuffix(e.Name(), ".bak") {
+			found = true
+		}
+	}
+	if !found {
+		t.Error("expected renew to archive the previous admin.pem as a .bak file")
+	}
+}
+
+func TestExpiring(t *testing.T) {
+	ca, _ := testCA(t)
+
+	if err := ca.CreateAdminKeyPair(); err != nil {
+		t.Fatalf("create admin key pair: %v", err)
+	}
+
+	expiring, err := ca.Expiring(leafCertValidity + 24*time.Hour)
+	if err != nil {
+		t.Fatalf("expiring: %v", err)
+	}
+
+	found := false
+	for _, name := range expiring {
+		if name == "admin" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("expected admin to be reported as expiring, got %v", expiring)
+	}
+
+	expiring, err = ca.Expiring(1 * time.Hour)
+	if err != nil {
+		t.Fatalf("expiring: %v", err)
+	}
+	if len(expiring) != 0 {
+		t.Errorf("expected no certs expiring within 1 hour, got %v", expiring)
+	}
+}
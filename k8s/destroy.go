@@ -0,0 +1,105 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+)
+
+// DestroyOptions controls how much of a cluster Destroy tears down.
+type DestroyOptions struct {
+	// PurgeSSHKey also deletes the SSH key uploaded to the DigitalOcean
+	// account.
+	PurgeSSHKey bool
+
+	// PurgeLocal also removes the CA, generated certs, kubeconfig, and
+	// other local state under k.dir.
+	PurgeLocal bool
+}
+
+const dropletDeleteRetries = 3
+
+// Destroy tears down everything a K8s instance has provisioned: master and
+// worker droplets, optionally the uploaded SSH key, and optionally the
+// local state directory. It reads the droplet IDs and SSH fingerprint from
+// state written during creation, so it works even if called from a fresh
+// process.
+func (k *K8s) Destroy(opts DestroyOptions) error {
+	state, err := k.loadState()
+	if err != nil {
+		return fmt.Errorf("could not read cluster state: %v", err)
+	}
+
+	workers, err := k.loadWorkerState()
+	if err != nil {
+		return fmt.Errorf("could not read worker state: %v", err)
+	}
+
+	ctx := context.Background()
+
+	var destroyed []string
+
+	for _, id := range state.MasterDropletIDs {
+		if err := k.deleteDropletWithRetry(ctx, id); err != nil {
+			return fmt.Errorf("could not delete master droplet %d: %v", id, err)
+		}
+		destroyed = append(destroyed, fmt.Sprintf("master droplet %d", id))
+	}
+
+	for _, w := range workers {
+		if err := k.deleteDropletWithRetry(ctx, w.DropletID); err != nil {
+			return fmt.Errorf("could not delete worker droplet %d: %v", w.DropletID, err)
+		}
+		destroyed = append(destroyed, fmt.Sprintf("worker droplet %d (%s)", w.DropletID, w.Name))
+	}
+
+	if state.LoadBalancerID != "" {
+		if _, err := k.doClient.LoadBalancers.Delete(ctx, state.LoadBalancerID); err != nil {
+			return fmt.Errorf("could not delete load balancer %s: %v", state.LoadBalancerID, err)
+		}
+		destroyed = append(destroyed, fmt.Sprintf("load balancer %s", state.LoadBalancerID))
+	}
+
+	if opts.PurgeSSHKey && state.SSHFingerprint != "" {
+		if _, err := k.doClient.Keys.DeleteByFingerprint(ctx, state.SSHFingerprint); err != nil {
+			return fmt.Errorf("could not delete ssh key %s: %v", state.SSHFingerprint, err)
+		}
+		destroyed = append(destroyed, fmt.Sprintf("ssh key %s", state.SSHFingerprint))
+	}
+
+	if opts.PurgeLocal {
+		if err := os.RemoveAll(k.dir); err != nil {
+			return fmt.Errorf("could not remove %s: %v", k.dir, err)
+		}
+		destroyed = append(destroyed, k.dir)
+	} else {
+		if err := k.saveState(&ClusterState{}); err != nil {
+			return err
+		}
+		if err := k.saveWorkerState(nil); err != nil {
+			return err
+		}
+	}
+
+	log.Printf("destroyed: %s", strings.Join(destroyed, ", "))
+
+	return nil
+}
+
+func (k *K8s) deleteDropletWithRetry(ctx context.Context, id int) error {
+	var lastErr error
+	for i := 0; i < dropletDeleteRetries; i++ {
+		_, err := k.doClient.Droplets.Delete(ctx, id)
+		if err == nil {
+			return nil
+		}
+
+		lastErr = err
+		time.Sleep(5 * time.Second)
+	}
+
+	return lastErr
+}
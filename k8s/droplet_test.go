@@ -0,0 +1,119 @@
+package k8s
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+
+	"github.com/digitalocean/godo"
+)
+
+func newTestDOClient(t *testing.T, mux *http.ServeMux) *godo.Client {
+	t.Helper()
+
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	client := godo.NewClient(nil)
+
+	u, err := url.Parse(server.URL + "/")
+	if err != nil {
+		t.Fatalf("parse test server url: %v", err)
+	}
+	client.BaseURL = u
+
+	return client
+}
+
+// TestCreateDropletWaitsForActive exercises the k.doClient seam end to end
+// against a fake DigitalOcean API: createDroplet must poll until the
+// droplet reports active with a public IPv4 address before returning it.
+func TestCreateDropletWaitsForActive(t *testing.T) {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("/v2/droplets", func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Errorf("unexpected method %s for /v2/droplets", r.Method)
+		}
+		fmt.Fprint(w, `{"droplet": {"id": 1, "name": "cs-test-master-nyc1"}}`)
+	})
+
+	gets := 0
+	mux.HandleFunc("/v2/droplets/1", func(w http.ResponseWriter, r *http.Request) {
+		gets++
+
+		status := "new"
+		if gets > 1 {
+			status = "active"
+		}
+
+		b, err := json.Marshal(map[string]interface{}{
+			"droplet": map[string]interface{}{
+				"id":     1,
+				"name":   "cs-test-master-nyc1",
+				"status": status,
+				"networks": map[string]interface{}{
+					"v4": []map[string]interface{}{
+						{"ip_address": "203.0.113.10", "type": "public"},
+					},
+				},
+			},
+		})
+		if err != nil {
+			t.Fatalf("marshal test response: %v", err)
+		}
+		w.Write(b)
+	})
+
+	k := newTestK8s(t)
+	k.doClient = newTestDOClient(t, mux)
+
+	cloudConfigPath, err := cloudConfigFixture(t)
+	if err != nil {
+		t.Fatalf("write cloud-config fixture: %v", err)
+	}
+
+	var onCreatedID int
+	droplet, err := k.createDroplet("cs-test-master-nyc1", "4gb", cloudConfigPath, "aa:bb:cc", func(id int) error {
+		onCreatedID = id
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("create droplet: %v", err)
+	}
+
+	if onCreatedID != droplet.ID {
+		t.Errorf("onCreated saw id %d, want %d", onCreatedID, droplet.ID)
+	}
+
+	if droplet.Status != "active" {
+		t.Errorf("droplet status = %q, want %q", droplet.Status, "active")
+	}
+	if got := droplet.Networks.V4[0].IPAddress; got != "203.0.113.10" {
+		t.Errorf("droplet ip = %q, want %q", got, "203.0.113.10")
+	}
+	if gets < 2 {
+		t.Errorf("expected createDroplet to poll at least twice before becoming active, got %d", gets)
+	}
+}
+
+func cloudConfigFixture(t *testing.T) (string, error) {
+	t.Helper()
+
+	f, err := ioutil.TempFile("", "cloud-config")
+	if err != nil {
+		return "", err
+	}
+	t.Cleanup(func() { os.Remove(f.Name()) })
+
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+
+	return f.Name(), nil
+}
@@ -0,0 +1,205 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/digitalocean/godo"
+)
+
+// ConfigureMasters provisions count CoreOS droplets (3 or 5 recommended)
+// and wires them into a single etcd2 cluster, then issues an apiserver
+// certificate whose SANs cover every master plus a shared load balancer
+// hostname. It returns the load balancer IP if a load balancer was
+// created, otherwise the public IPs of the masters.
+func (k *K8s) ConfigureMasters(count int, sshFingerprint string) ([]string, error) {
+	if count < 1 {
+		return nil, fmt.Errorf("count must be at least 1")
+	}
+	if k.ca == nil {
+		return nil, fmt.Errorf("ca has not been initialized")
+	}
+
+	var masters []*godo.Droplet
+	for i := 0; i < count; i++ {
+		name := fmt.Sprintf("cs-%s-master-%s-%d", k.name, k.region, i)
+		log.Printf("booting master: %s", name)
+
+		cloudConfigPath, err := k.prepareMasterCloudConfig()
+		if err != nil {
+			return nil, err
+		}
+
+		droplet, err := k.createDroplet(name, nodeSize, cloudConfigPath, sshFingerprint, func(id int) error {
+			state, err := k.loadState()
+			if err != nil {
+				return err
+			}
+			state.MasterDropletIDs = append(state.MasterDropletIDs, id)
+			return k.saveState(state)
+		})
+		os.Remove(cloudConfigPath)
+		if err != nil {
+			return nil, err
+		}
+
+		masters = append(masters, droplet)
+	}
+
+	lbHost := fmt.Sprintf("%s-master.%s.doks", k.name, k.region)
+
+	hosts := []string{lbHost}
+	for _, m := range masters {
+		hosts = append(hosts, m.Networks.V4[0].IPAddress)
+	}
+
+	if err := k.ca.CreateAPIServerKeyPair(hosts...); err != nil {
+		return nil, fmt.Errorf("could not create api server key pair: %v", err)
+	}
+
+	initialCluster := etcdInitialCluster(masters)
+
+	for i, m := range masters {
+		publicIP := m.Networks.V4[0].IPAddress
+
+		if err := k.installMasterAuth(publicIP); err != nil {
+			return nil, fmt.Errorf("unable to configure tls on %s: %v", publicIP, err)
+		}
+
+		etcdName := fmt.Sprintf("master-%d", i)
+		if err := k.installEtcdCluster(publicIP, etcdName, privateIP(m), initialCluster); err != nil {
+			return nil, fmt.Errorf("unable to configure etcd on %s: %v", publicIP, err)
+		}
+	}
+
+	k.masterIP = masters[0].Networks.V4[0].IPAddress
+
+	lb, err := k.createMasterLoadBalancer(masters)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create load balancer: %v", err)
+	}
+
+	state, err := k.loadState()
+	if err != nil {
+		return nil, err
+	}
+
+	if lb != nil {
+		state.LoadBalancerID = lb.ID
+		k.masterIP = lb.IP
+	}
+
+	state.MasterIP = k.masterIP
+	if err := k.saveState(state); err != nil {
+		return nil, err
+	}
+
+	if lb != nil {
+		return []string{lb.IP}, nil
+	}
+
+	var masterIPs []string
+	for _, m := range masters {
+		masterIPs = append(masterIPs, m.Networks.V4[0].IPAddress)
+	}
+
+	return masterIPs, nil
+}
+
+// createMasterLoadBalancer fronts :443 on every master with a DigitalOcean
+// load balancer.
+func (k *K8s) createMasterLoadBalancer(masters []*godo.Droplet) (*godo.LoadBalancer, error) {
+	var dropletIDs []int
+	for _, m := range masters {
+		dropletIDs = append(dropletIDs, m.ID)
+	}
+
+	lbRequest := &godo.LoadBalancerRequest{
+		Name:       fmt.Sprintf("cs-%s-master-%s", k.name, k.region),
+		Region:     k.region,
+		DropletIDs: dropletIDs,
+		ForwardingRules: []godo.ForwardingRule{
+			{
+				EntryProtocol:  "tcp",
+				EntryPort:      443,
+				TargetProtocol: "tcp",
+				TargetPort:     443,
+			},
+		},
+		HealthCheck: &godo.HealthCheck{
+			Protocol: "tcp",
+			Port:     443,
+		},
+	}
+
+	lb, _, err := k.doClient.LoadBalancers.Create(context.Background(), lbRequest)
+	if err != nil {
+		return nil, err
+	}
+
+	return lb, nil
+}
+
+// installEtcdCluster pushes a systemd drop-in with this node's etcd2
+// cluster peer settings and restarts the service so it joins the rest of
+// the quorum.
+func (k *K8s) installEtcdCluster(publicIP, etcdName, privateIP, initialCluster string) error {
+	host := "core@" + publicIP
+
+	f, err := ioutil.TempFile("", "etcd-cluster")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	content := fmt.Sprintf(`[Service]
+Environment=ETCD_NAME=%s
+Environment=ETCD_INITIAL_ADVERTISE_PEER_URLS=https://%s:2380
+Environment=ETCD_LISTEN_PEER_URLS=https://0.0.0.0:2380
+Environment=ETCD_INITIAL_CLUSTER=%s
+Environment=ETCD_INITIAL_CLUSTER_STATE=new
+`, etcdName, privateIP, initialCluster)
+
+	if _, err := f.WriteString(content); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	dropInDir := "/run/systemd/system/etcd2.service.d"
+	if err := k.sshCmd(host, "sudo", "mkdir", "-p", dropInDir); err != nil {
+		return err
+	}
+
+	if err := k.scp(f.Name(), fmt.Sprintf("%s:%s/40-cluster.conf", host, dropInDir)); err != nil {
+		return err
+	}
+
+	return k.sshCmd(host, "sudo", "systemctl", "daemon-reload", "&&", "sudo", "systemctl", "restart", "etcd2")
+}
+
+// etcdInitialCluster builds the ETCD_INITIAL_CLUSTER value from each
+// master's private IP, e.g. "master-0=https://10.0.0.1:2380,...".
+func etcdInitialCluster(masters []*godo.Droplet) string {
+	var members []string
+	for i, m := range masters {
+		members = append(members, fmt.Sprintf("master-%d=https://%s:2380", i, privateIP(m)))
+	}
+
+	return strings.Join(members, ",")
+}
+
+func privateIP(d *godo.Droplet) string {
+	for _, n := range d.Networks.V4 {
+		if n.Type == "private" {
+			return n.IPAddress
+		}
+	}
+
+	return ""
+}
@@ -1,7 +1,7 @@
 package k8s
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
 	"io/ioutil"
 	"log"
@@ -14,6 +14,7 @@ import (
 	"github.com/alecthomas/template"
 	"github.com/bryanl/doit-provider-k8s/certauth"
 	"github.com/digitalocean/godo"
+	"golang.org/x/oauth2"
 )
 
 var (
@@ -21,24 +22,81 @@ var (
 	nodeSize  = "4gb"
 )
 
+const (
+	dropletPollInterval = 5 * time.Second
+	maxDropletPolls     = 60
+)
+
 // K8s builds a k8s installation.
 type K8s struct {
 	dir    string
 	region string
 	name   string
+
+	ca             *certauth.CA
+	sshFingerprint string
+	masterIP       string
+
+	doClient *godo.Client
 }
 
-// New creates a K8s instance.
-func New(name, region, dir string) (*K8s, error) {
+// New creates a K8s instance. By default the DigitalOcean client is built
+// from the DIGITALOCEAN_TOKEN environment variable; pass WithToken or
+// WithClient to override that.
+func New(name, region, dir string, opts ...func(*K8s)) (*K8s, error) {
 	if err := os.MkdirAll(dir, 0700); err != nil {
 		return nil, err
 	}
 
-	return &K8s{
+	k := &K8s{
 		name:   name,
 		region: region,
 		dir:    dir,
-	}, nil
+	}
+
+	for _, opt := range opts {
+		opt(k)
+	}
+
+	if k.doClient == nil {
+		token := os.Getenv("DIGITALOCEAN_TOKEN")
+		if token == "" {
+			return nil, fmt.Errorf("DIGITALOCEAN_TOKEN must be set")
+		}
+
+		k.doClient = doClient(token)
+	}
+
+	return k, nil
+}
+
+// WithToken builds the DigitalOcean API client from an explicit token
+// instead of the DIGITALOCEAN_TOKEN environment variable.
+func WithToken(token string) func(*K8s) {
+	return func(k *K8s) {
+		k.doClient = doClient(token)
+	}
+}
+
+// WithClient injects a pre-built DigitalOcean API client, primarily so
+// tests can supply a fake.
+func WithClient(client *godo.Client) func(*K8s) {
+	return func(k *K8s) {
+		k.doClient = client
+	}
+}
+
+type tokenSource struct {
+	AccessToken string
+}
+
+func (t *tokenSource) Token() (*oauth2.Token, error) {
+	return &oauth2.Token{AccessToken: t.AccessToken}, nil
+}
+
+func doClient(token string) *godo.Client {
+	oauthClient := oauth2.NewClient(context.Background(), &tokenSource{AccessToken: token})
+	return godo.NewClient(oauthClient)
 }
 
 // Init initializes k8s.
@@ -58,16 +116,51 @@ func (k *K8s) Init() error {
 		return fmt.Errorf("could not create api server key pair: %v", err)
 	}
 
-	err = ca.CreateWorkerKeyPair("worker.example.com", "172.17.0.5")
+	err = ca.CreateAdminKeyPair()
+	if err != nil {
+		return fmt.Errorf("could not create admin key pair: %v", err)
+	}
+
+	k.ca = ca
+
+	return nil
+}
+
+// LoadCA reconstructs the certificate authority from the root cert and key
+// already written to k.dir, so methods that need k.ca (RotateMasterCerts,
+// Status) work from a fresh process rather than only the one that ran
+// Init.
+func (k *K8s) LoadCA() error {
+	ca, err := certauth.New(k.dir)
 	if err != nil {
-		return fmt.Errorf("could not create worker key pair: %v", err)
+		return fmt.Errorf("could not init ca: %v", err)
 	}
 
-	err = ca.CreateAdminKeyPair()
+	if err := ca.LoadRoot(); err != nil {
+		return fmt.Errorf("could not load ca: %v", err)
+	}
+
+	k.ca = ca
+
+	return nil
+}
+
+// ensureMasterIP populates k.masterIP from persisted state if it isn't
+// already set in this process, so RotateMasterCerts, AddWorker, and Status
+// keep working after a process restart instead of only within the process
+// that ran ConfigureMaster/ConfigureMasters.
+func (k *K8s) ensureMasterIP() error {
+	if k.masterIP != "" {
+		return nil
+	}
+
+	state, err := k.loadState()
 	if err != nil {
-		return fmt.Errorf("could not create admin key pair: %v", err)
+		return err
 	}
 
+	k.masterIP = state.MasterIP
+
 	return nil
 }
 
@@ -94,13 +187,31 @@ func (k *K8s) CreateSSHKey() (string, error) {
 	fingerprint := parts[1]
 	log.Println("fingerprint is", fingerprint)
 
-	doitOut, err := k.doit("ssh-key", "get", fingerprint)
-	log.Println(doitOut)
+	k.sshFingerprint = fingerprint
+
+	state, err := k.loadState()
+	if err != nil {
+		return "", err
+	}
+	state.SSHFingerprint = fingerprint
+	if err := k.saveState(state); err != nil {
+		return "", err
+	}
+
+	_, _, err = k.doClient.Keys.GetByFingerprint(context.Background(), fingerprint)
 	if err != nil {
 		publicKeyPath := privateKeyPath + ".pub"
 		log.Println("uploading key to api:", publicKeyPath)
 
-		_, err = k.doit("ssh-key", "import", k.name, "--public-key-file", publicKeyPath)
+		pubKey, err := ioutil.ReadFile(publicKeyPath)
+		if err != nil {
+			return "", fmt.Errorf("unable to read public key: %v", err)
+		}
+
+		_, _, err = k.doClient.Keys.Create(context.Background(), &godo.KeyCreateRequest{
+			Name:      k.name,
+			PublicKey: string(pubKey),
+		})
 		if err != nil {
 			return "", fmt.Errorf("unable to upload public key: %v", err)
 		}
@@ -128,37 +239,115 @@ func (k *K8s) ConfigureMaster(sshFingerprint string) error {
 	name := fmt.Sprintf("cs-%s-master-%s", k.name, k.region)
 	log.Printf("booting master: %s", name)
 
-	out, err := k.doit("droplet", "create", name,
-		"--output", "json",
-		"--image", nodeImage,
-		"--region", k.region,
-		"--size", nodeSize,
-		"--ssh-keys", sshFingerprint,
-		"--user-data-file", cloudConfigPath,
-		"--wait")
+	droplet, err := k.createDroplet(name, nodeSize, cloudConfigPath, sshFingerprint, func(id int) error {
+		state, err := k.loadState()
+		if err != nil {
+			return err
+		}
+		state.MasterDropletIDs = []int{id}
+		return k.saveState(state)
+	})
 	if err != nil {
 		return err
 	}
 
-	var droplets []godo.Droplet
-	err = json.Unmarshal([]byte(out), &droplets)
+	masterIP := droplet.Networks.V4[0].IPAddress
+	log.Printf("master ip is %s", masterIP)
+
+	err = k.installMasterAuth(masterIP)
+	if err != nil {
+		return fmt.Errorf("unable to configure tls: %v", err)
+	}
+
+	k.masterIP = masterIP
+
+	state, err := k.loadState()
 	if err != nil {
 		return err
 	}
+	state.MasterIP = masterIP
+	if err := k.saveState(state); err != nil {
+		return err
+	}
 
-	if l := len(droplets); l != 1 {
-		return fmt.Errorf("received unexpected number of droplets: %d", l)
+	err = k.WriteKubeconfig(filepath.Join(k.dir, "kubeconfig"))
+	if err != nil {
+		return fmt.Errorf("unable to write kubeconfig: %v", err)
 	}
 
-	masterIP := droplets[0].Networks.V4[0].IPAddress
-	log.Printf("master ip is %s", masterIP)
+	return nil
+}
 
-	err = k.installMasterAuth(masterIP)
+// createDroplet boots a droplet running the given cloud-config and waits for
+// it to become active with a public IPv4 address assigned. onCreated, if
+// non-nil, is called with the droplet's ID as soon as it exists (before
+// waiting for it to boot) so the caller can persist the ID to state right
+// away: the droplet is already billing at that point, and a slow boot that
+// trips the wait timeout must not leave it untracked. Once the droplet has
+// been created, the returned droplet carries at least its ID, even when an
+// error is also returned; if no droplet was created, it is nil.
+func (k *K8s) createDroplet(name, size, cloudConfigPath, sshFingerprint string, onCreated func(id int) error) (*godo.Droplet, error) {
+	userData, err := ioutil.ReadFile(cloudConfigPath)
 	if err != nil {
-		return fmt.Errorf("unable to configure tls: %v", err)
+		return nil, err
 	}
 
-	return nil
+	createRequest := &godo.DropletCreateRequest{
+		Name:     name,
+		Region:   k.region,
+		Size:     size,
+		Image:    godo.DropletCreateImage{Slug: nodeImage},
+		SSHKeys:  []godo.DropletCreateSSHKey{{Fingerprint: sshFingerprint}},
+		UserData: string(userData),
+	}
+
+	ctx := context.Background()
+
+	droplet, _, err := k.doClient.Droplets.Create(ctx, createRequest)
+	if err != nil {
+		return nil, fmt.Errorf("unable to create droplet: %v", err)
+	}
+
+	if onCreated != nil {
+		if err := onCreated(droplet.ID); err != nil {
+			return droplet, err
+		}
+	}
+
+	return k.waitForActiveDroplet(ctx, droplet.ID)
+}
+
+// waitForActiveDroplet polls the API until the droplet is active and has a
+// public IPv4 address, or gives up after maxDropletPolls attempts. The
+// returned droplet carries at least its ID even on timeout, so a caller
+// that already persisted the ID via createDroplet's onCreated can still
+// log or act on the rest of what's known about it.
+func (k *K8s) waitForActiveDroplet(ctx context.Context, id int) (*godo.Droplet, error) {
+	last := &godo.Droplet{ID: id}
+
+	for i := 0; i < maxDropletPolls; i++ {
+		droplet, _, err := k.doClient.Droplets.Get(ctx, id)
+		if err != nil {
+			return last, fmt.Errorf("unable to get droplet %d: %v", id, err)
+		}
+		last = droplet
+
+		hasPublicIP := false
+		for _, n := range droplet.Networks.V4 {
+			if n.Type == "public" {
+				hasPublicIP = true
+				break
+			}
+		}
+
+		if droplet.Status == "active" && hasPublicIP {
+			return droplet, nil
+		}
+
+		time.Sleep(dropletPollInterval)
+	}
+
+	return last, fmt.Errorf("timed out waiting for droplet %d to become active", id)
 }
 
 func (k *K8s) prepareMasterCloudConfig() (string, error) {
@@ -205,10 +394,13 @@ func (k *K8s) installMasterAuth(masterIP string) error {
 }
 
 func (k *K8s) sshCopy(host, remoteDir, name string) error {
+	return k.scp(filepath.Join(k.dir, name), fmt.Sprintf("%s:%s/%s", host, remoteDir, name))
+}
+
+func (k *K8s) scp(localPath, remoteSpec string) error {
 	cmd := exec.Command("scp", "-o", "stricthostkeychecking=no",
 		"-i", filepath.Join(k.dir, "k8s.key"),
-		filepath.Join(k.dir, name),
-		fmt.Sprintf("%s:%s/%s", host, remoteDir, name))
+		localPath, remoteSpec)
 	log.Println(cmd.Args)
 	out, err := cmd.Output()
 	if err != nil {
@@ -244,13 +436,5 @@ func (k *K8s) sshCmd(host string, args ...string) error {
 	return fmt.Errorf("command failed %d times", maxFailCount)
 }
 
-func (k *K8s) doit(args ...string) (string, error) {
-	args = append(args, "-o", "json")
-	log.Println("running doit", args)
-	cmd := exec.Command("doit", args...)
-	out, err := cmd.Output()
-	return string(out), err
-}
-
 //go:generate embed file -var masterCloudConfig -source cloud-config.yaml
 var masterCloudConfig = "#cloud-config\n\ncoreos:\n  etcd2:\n    advertise-client-urls: https://$public_ipv4:2379,https://$public_ipv4:4001\n    listen-client-urls: https://0.0.0.0:2379,https://0.0.0.0:4001\n  flannel:\n    etcd_cafile: /home/core/ssl/ca.pem\n    etcd_certfile: /home/core/ssl/admin.pem\n    etcd_keyfile: /home/core/ssl/admin-key.pem\n  locksmith:\n    etcd_cafile: /home/core/ssl/ca.pem\n    etcd_certfile: /home/core/ssl/client.pem\n    etcd_keyfile: /home/core/ssl/client-key.pem\n  units:\n    - name: etcd2.service\n      command: start\n    - name: flanneld.service\n      drop-ins: \n      - name: 50-network-config.conf\n        content: |\n          [Service]\n          ExecStartPre=/usr/bin/etcdctl set /coreos.com/network/config '{ \"Network\": \"10.3.0.0/16\" }'\n      command: start\nwrite_files:\n  - path: /run/systemd/system/etcd2.service.d/30-certificates.conf\n    permissions: 0644\n    content: |\n      [Service]\n      # client environment variables\n      Environment=ETCD_CA_FILE=/home/core/ssl/ca.pem\n      Environment=ETCD_CERT_FILE=/home/core/ssl/apiserver.pem\n      Environment=ETCD_KEY_FILE=/home/core/ssl/apiserver-key.pem\n\n"
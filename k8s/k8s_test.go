@@ -0,0 +1,96 @@
+package k8s
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func newTestK8s(t *testing.T) *K8s {
+	t.Helper()
+
+	dir, err := ioutil.TempDir("", "k8s")
+	if err != nil {
+		t.Fatalf("create temp dir: %v", err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	return &K8s{name: "test", region: "nyc1", dir: dir}
+}
+
+func TestClusterStateRoundTrip(t *testing.T) {
+	k := newTestK8s(t)
+
+	state := &ClusterState{
+		MasterDropletIDs: []int{1, 2, 3},
+		SSHFingerprint:   "aa:bb:cc",
+		LoadBalancerID:   "lb-1",
+	}
+
+	if err := k.saveState(state); err != nil {
+		t.Fatalf("save state: %v", err)
+	}
+
+	got, err := k.loadState()
+	if err != nil {
+		t.Fatalf("load state: %v", err)
+	}
+
+	if got.SSHFingerprint != state.SSHFingerprint {
+		t.Errorf("SSHFingerprint = %q, want %q", got.SSHFingerprint, state.SSHFingerprint)
+	}
+	if len(got.MasterDropletIDs) != len(state.MasterDropletIDs) {
+		t.Errorf("MasterDropletIDs = %v, want %v", got.MasterDropletIDs, state.MasterDropletIDs)
+	}
+	if got.LoadBalancerID != state.LoadBalancerID {
+		t.Errorf("LoadBalancerID = %q, want %q", got.LoadBalancerID, state.LoadBalancerID)
+	}
+}
+
+func TestLoadStateMissingFile(t *testing.T) {
+	k := newTestK8s(t)
+
+	state, err := k.loadState()
+	if err != nil {
+		t.Fatalf("load state: %v", err)
+	}
+	if state.SSHFingerprint != "" || len(state.MasterDropletIDs) != 0 {
+		t.Errorf("expected empty state, got %+v", state)
+	}
+}
+
+func TestWorkerStateRoundTrip(t *testing.T) {
+	k := newTestK8s(t)
+
+	workers := []*Worker{
+		{Name: "cs-test-worker-nyc1-0", DropletID: 1, PublicIP: "203.0.113.1", PrivateIP: "10.0.0.1"},
+	}
+
+	if err := k.saveWorkerState(workers); err != nil {
+		t.Fatalf("save worker state: %v", err)
+	}
+
+	got, err := k.loadWorkerState()
+	if err != nil {
+		t.Fatalf("load worker state: %v", err)
+	}
+
+	if len(got) != 1 {
+		t.Fatalf("loadWorkerState() returned %d workers, want 1", len(got))
+	}
+	if got[0].Name != workers[0].Name || got[0].DropletID != workers[0].DropletID {
+		t.Errorf("loadWorkerState() = %+v, want %+v", got[0], workers[0])
+	}
+}
+
+func TestLoadWorkerStateMissingFile(t *testing.T) {
+	k := newTestK8s(t)
+
+	workers, err := k.loadWorkerState()
+	if err != nil {
+		t.Fatalf("load worker state: %v", err)
+	}
+	if len(workers) != 0 {
+		t.Errorf("expected no workers, got %v", workers)
+	}
+}
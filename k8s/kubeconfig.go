@@ -0,0 +1,85 @@
+package k8s
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+
+	"github.com/alecthomas/template"
+)
+
+// WriteKubeconfig renders an admin kubeconfig for the cluster's master and
+// writes it to path, so it can be copied into ~/.kube/config and used
+// directly with kubectl.
+func (k *K8s) WriteKubeconfig(path string) error {
+	b, err := k.renderKubeconfig()
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, b, 0600)
+}
+
+func (k *K8s) renderKubeconfig() ([]byte, error) {
+	if k.masterIP == "" {
+		return nil, fmt.Errorf("master has not been configured")
+	}
+
+	ca, err := ioutil.ReadFile(filepath.Join(k.dir, "ca.pem"))
+	if err != nil {
+		return nil, fmt.Errorf("could not read ca.pem: %v", err)
+	}
+
+	cert, err := ioutil.ReadFile(filepath.Join(k.dir, "admin.pem"))
+	if err != nil {
+		return nil, fmt.Errorf("could not read admin.pem: %v", err)
+	}
+
+	key, err := ioutil.ReadFile(filepath.Join(k.dir, "admin-key.pem"))
+	if err != nil {
+		return nil, fmt.Errorf("could not read admin-key.pem: %v", err)
+	}
+
+	t, err := template.New("kubeconfig").Parse(kubeconfigTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	args := map[string]interface{}{
+		"Server":                   fmt.Sprintf("https://%s:443", k.masterIP),
+		"ClusterName":              k.name,
+		"CertificateAuthorityData": base64.StdEncoding.EncodeToString(ca),
+		"ClientCertificateData":    base64.StdEncoding.EncodeToString(cert),
+		"ClientKeyData":            base64.StdEncoding.EncodeToString(key),
+	}
+
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, args); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+//go:generate embed file -var kubeconfigTemplate -source kubeconfig.yaml
+var kubeconfigTemplate = `apiVersion: v1
+kind: Config
+clusters:
+- cluster:
+    certificate-authority-data: {{.CertificateAuthorityData}}
+    server: {{.Server}}
+  name: {{.ClusterName}}
+contexts:
+- context:
+    cluster: {{.ClusterName}}
+    user: kube-admin
+  name: {{.ClusterName}}
+current-context: {{.ClusterName}}
+users:
+- name: kube-admin
+  user:
+    client-certificate-data: {{.ClientCertificateData}}
+    client-key-data: {{.ClientKeyData}}
+`
@@ -0,0 +1,87 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+)
+
+// RotateMasterCerts renews the apiserver key pair under the existing CA
+// with SANs covering every master plus the load balancer hostname (if an
+// HA cluster has one), copies the fresh material to every master, and
+// restarts etcd2 and kube-apiserver on each so they pick it up.
+func (k *K8s) RotateMasterCerts() error {
+	if k.ca == nil {
+		if err := k.LoadCA(); err != nil {
+			return fmt.Errorf("ca has not been initialized: %v", err)
+		}
+	}
+	if err := k.ensureMasterIP(); err != nil {
+		return err
+	}
+	if k.masterIP == "" {
+		return fmt.Errorf("master has not been configured")
+	}
+
+	state, err := k.loadState()
+	if err != nil {
+		return fmt.Errorf("could not read cluster state: %v", err)
+	}
+
+	masterIPs, err := k.masterPublicIPs(state)
+	if err != nil {
+		return fmt.Errorf("could not determine master ips: %v", err)
+	}
+	if len(masterIPs) == 0 {
+		masterIPs = []string{k.masterIP}
+	}
+
+	hosts := masterIPs
+	if state.LoadBalancerID != "" {
+		hosts = append(hosts, fmt.Sprintf("%s-master.%s.doks", k.name, k.region))
+	}
+
+	if err := k.ca.RenewAPIServerKeyPair(hosts...); err != nil {
+		return fmt.Errorf("could not renew api server key pair: %v", err)
+	}
+
+	for _, ip := range masterIPs {
+		host := "core@" + ip
+
+		for _, f := range []string{"apiserver.pem", "apiserver-key.pem"} {
+			if err := k.sshCopy(host, "/home/core/ssl", f); err != nil {
+				return fmt.Errorf("could not copy %s to %s: %v", f, ip, err)
+			}
+		}
+
+		if err := k.sshCmd(host, "sudo", "systemctl", "restart", "etcd2", "kube-apiserver"); err != nil {
+			return fmt.Errorf("could not restart services on %s: %v", ip, err)
+		}
+	}
+
+	return nil
+}
+
+// masterPublicIPs returns the current public IPv4 address of every master
+// droplet tracked in state, so cert rotation reaches all of them instead of
+// just the one k.masterIP happens to point at (the load balancer's IP in
+// an HA cluster).
+func (k *K8s) masterPublicIPs(state *ClusterState) ([]string, error) {
+	var ips []string
+
+	ctx := context.Background()
+	for _, id := range state.MasterDropletIDs {
+		droplet, _, err := k.doClient.Droplets.Get(ctx, id)
+		if err != nil {
+			return nil, fmt.Errorf("could not get master droplet %d: %v", id, err)
+		}
+
+		for _, n := range droplet.Networks.V4 {
+			if n.Type == "public" {
+				ips = append(ips, n.IPAddress)
+				break
+			}
+		}
+	}
+
+	return ips, nil
+}
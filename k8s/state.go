@@ -0,0 +1,49 @@
+package k8s
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// ClusterState tracks the droplets and other resources a K8s instance has
+// provisioned, so a later run (in particular Destroy) can find and clean
+// them up without depending on in-memory state from the run that created
+// them.
+type ClusterState struct {
+	MasterDropletIDs []int  `json:"master_droplet_ids,omitempty"`
+	MasterIP         string `json:"master_ip,omitempty"`
+	SSHFingerprint   string `json:"ssh_fingerprint,omitempty"`
+	LoadBalancerID   string `json:"load_balancer_id,omitempty"`
+}
+
+func (k *K8s) statePath() string {
+	return filepath.Join(k.dir, "cluster-state.json")
+}
+
+func (k *K8s) loadState() (*ClusterState, error) {
+	b, err := ioutil.ReadFile(k.statePath())
+	if os.IsNotExist(err) {
+		return &ClusterState{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var s ClusterState
+	if err := json.Unmarshal(b, &s); err != nil {
+		return nil, err
+	}
+
+	return &s, nil
+}
+
+func (k *K8s) saveState(s *ClusterState) error {
+	b, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(k.statePath(), b, 0600)
+}
@@ -0,0 +1,105 @@
+package k8s
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const certExpiryWarningWindow = 30 * 24 * time.Hour
+
+// DropletStatusUnknown is used when a droplet could not be fetched, e.g.
+// it has been deleted outside of this tool.
+const DropletStatusUnknown = "unknown"
+
+// DropletStatusEntry reports a provisioned droplet's name, ID, and current
+// DigitalOcean status.
+type DropletStatusEntry struct {
+	Name   string `json:"name"`
+	ID     int    `json:"id"`
+	Status string `json:"status"`
+}
+
+// ClusterStatus summarizes the health of a provisioned cluster.
+type ClusterStatus struct {
+	Droplets         []DropletStatusEntry `json:"droplets"`
+	ExpiringCerts    []string             `json:"expiring_certs"`
+	APIServerHealthy bool                 `json:"api_server_healthy"`
+}
+
+// Status reports droplet health, certs nearing expiration, and whether the
+// master API responds, giving operators an at-a-glance view of an
+// otherwise one-shot cluster.
+func (k *K8s) Status() (*ClusterStatus, error) {
+	state, err := k.loadState()
+	if err != nil {
+		return nil, fmt.Errorf("could not read cluster state: %v", err)
+	}
+
+	workers, err := k.loadWorkerState()
+	if err != nil {
+		return nil, fmt.Errorf("could not read worker state: %v", err)
+	}
+
+	ctx := context.Background()
+	status := &ClusterStatus{}
+
+	for _, id := range state.MasterDropletIDs {
+		status.Droplets = append(status.Droplets, k.dropletStatus(ctx, id, ""))
+	}
+	for _, w := range workers {
+		status.Droplets = append(status.Droplets, k.dropletStatus(ctx, w.DropletID, w.Name))
+	}
+
+	if k.ca == nil {
+		// Best effort: a fresh process has no in-memory CA, but one may
+		// already exist on disk from a previous run.
+		_ = k.LoadCA()
+	}
+	if k.ca != nil {
+		expiring, err := k.ca.Expiring(certExpiryWarningWindow)
+		if err != nil {
+			return nil, fmt.Errorf("could not check cert expirations: %v", err)
+		}
+		status.ExpiringCerts = expiring
+	}
+
+	if k.masterIP == "" {
+		// Best effort: a fresh process has no in-memory master IP, but one
+		// may already exist in persisted state from a previous run.
+		_ = k.ensureMasterIP()
+	}
+	if k.masterIP != "" {
+		status.APIServerHealthy = k.probeAPIServer()
+	}
+
+	return status, nil
+}
+
+func (k *K8s) dropletStatus(ctx context.Context, id int, fallbackName string) DropletStatusEntry {
+	d, _, err := k.doClient.Droplets.Get(ctx, id)
+	if err != nil {
+		return DropletStatusEntry{Name: fallbackName, ID: id, Status: DropletStatusUnknown}
+	}
+
+	return DropletStatusEntry{Name: d.Name, ID: d.ID, Status: d.Status}
+}
+
+func (k *K8s) probeAPIServer() bool {
+	client := &http.Client{
+		Timeout: 5 * time.Second,
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+	}
+
+	resp, err := client.Get(fmt.Sprintf("https://%s:443/healthz", k.masterIP))
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+
+	return resp.StatusCode == http.StatusOK
+}
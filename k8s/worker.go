@@ -0,0 +1,255 @@
+package k8s
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/alecthomas/template"
+)
+
+// Worker is a single worker node joined to the cluster.
+type Worker struct {
+	Name      string `json:"name"`
+	DropletID int    `json:"droplet_id"`
+	PublicIP  string `json:"public_ip"`
+	PrivateIP string `json:"private_ip"`
+}
+
+// AddWorker provisions a single worker droplet of the given size, generates
+// a key pair for it from the droplet's real IPs, and joins it to the
+// cluster. If a worker with this name is already tracked in state, it is
+// returned as-is, making the operation safe to call repeatedly.
+func (k *K8s) AddWorker(name, size string) (*Worker, error) {
+	if k.ca == nil {
+		return nil, fmt.Errorf("ca has not been initialized")
+	}
+	if err := k.ensureMasterIP(); err != nil {
+		return nil, err
+	}
+	if k.masterIP == "" {
+		return nil, fmt.Errorf("master has not been configured")
+	}
+
+	workers, err := k.loadWorkerState()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, w := range workers {
+		if w.Name == name {
+			log.Printf("worker %s already exists, skipping", name)
+			return w, nil
+		}
+	}
+
+	cloudConfigPath, err := k.prepareWorkerCloudConfig()
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(cloudConfigPath)
+
+	droplet, err := k.createDroplet(name, size, cloudConfigPath, k.sshFingerprint, func(id int) error {
+		workers, err := k.loadWorkerState()
+		if err != nil {
+			return err
+		}
+		workers = append(workers, &Worker{Name: name, DropletID: id})
+		return k.saveWorkerState(workers)
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to create worker droplet: %v", err)
+	}
+
+	w := &Worker{
+		Name:      name,
+		DropletID: droplet.ID,
+		PublicIP:  droplet.Networks.V4[0].IPAddress,
+	}
+	for _, n := range droplet.Networks.V4 {
+		if n.Type == "private" {
+			w.PrivateIP = n.IPAddress
+		}
+	}
+
+	err = k.ca.CreateWorkerKeyPair(w.Name, w.PublicIP)
+	if err != nil {
+		return nil, fmt.Errorf("could not create worker key pair: %v", err)
+	}
+
+	err = k.installWorkerAuth(w)
+	if err != nil {
+		return nil, fmt.Errorf("unable to configure tls: %v", err)
+	}
+
+	// Replace the partial entry persisted by createDroplet's onCreated
+	// callback with the fully populated worker now that it's active.
+	workers, err = k.loadWorkerState()
+	if err != nil {
+		return nil, err
+	}
+	replaced := false
+	for i, existing := range workers {
+		if existing.Name == w.Name {
+			workers[i] = w
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		workers = append(workers, w)
+	}
+	if err := k.saveWorkerState(workers); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// AddWorkerPool provisions count worker droplets of the given size and joins
+// them to the cluster.
+func (k *K8s) AddWorkerPool(count int, size string) ([]*Worker, error) {
+	existing, err := k.loadWorkerState()
+	if err != nil {
+		return nil, err
+	}
+
+	var added []*Worker
+	for i := len(existing); i < len(existing)+count; i++ {
+		name := fmt.Sprintf("cs-%s-worker-%s-%d", k.name, k.region, i)
+
+		w, err := k.AddWorker(name, size)
+		if err != nil {
+			return added, err
+		}
+
+		added = append(added, w)
+	}
+
+	return added, nil
+}
+
+// RemoveWorker removes a worker droplet and drops it from local state.
+func (k *K8s) RemoveWorker(name string) error {
+	workers, err := k.loadWorkerState()
+	if err != nil {
+		return err
+	}
+
+	var remaining []*Worker
+	var found *Worker
+	for _, w := range workers {
+		if w.Name == name {
+			found = w
+			continue
+		}
+		remaining = append(remaining, w)
+	}
+
+	if found == nil {
+		return fmt.Errorf("worker %s not found", name)
+	}
+
+	if _, err := k.doClient.Droplets.Delete(context.Background(), found.DropletID); err != nil {
+		return fmt.Errorf("unable to delete droplet %d: %v", found.DropletID, err)
+	}
+
+	return k.saveWorkerState(remaining)
+}
+
+// installWorkerAuth stages the worker's CA, cert, and key under
+// /etc/kubernetes/ssl. The cert and key are renamed to the generic
+// worker.pem/worker-key.pem the kubelet unit in workerCloudConfig
+// references, since certauth.CreateWorkerKeyPair writes them locally as
+// <name>-worker.pem/<name>-worker-key.pem.
+func (k *K8s) installWorkerAuth(w *Worker) error {
+	host := "core@" + w.PublicIP
+	stageDir := "/etc/kubernetes/ssl"
+
+	err := k.sshCmd(host, "mkdir", "-p", stageDir)
+	if err != nil {
+		return err
+	}
+
+	if err := k.sshCopy(host, stageDir, "ca.pem"); err != nil {
+		return err
+	}
+
+	remoteNames := map[string]string{
+		fmt.Sprintf("%s-worker.pem", w.Name):     "worker.pem",
+		fmt.Sprintf("%s-worker-key.pem", w.Name): "worker-key.pem",
+	}
+	for local, remote := range remoteNames {
+		src := filepath.Join(k.dir, local)
+		dst := fmt.Sprintf("%s:%s/%s", host, stageDir, remote)
+		if err := k.scp(src, dst); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (k *K8s) workerStatePath() string {
+	return filepath.Join(k.dir, "workers.json")
+}
+
+func (k *K8s) loadWorkerState() ([]*Worker, error) {
+	b, err := ioutil.ReadFile(k.workerStatePath())
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var workers []*Worker
+	if err := json.Unmarshal(b, &workers); err != nil {
+		return nil, err
+	}
+
+	return workers, nil
+}
+
+func (k *K8s) saveWorkerState(workers []*Worker) error {
+	b, err := json.MarshalIndent(workers, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(k.workerStatePath(), b, 0600)
+}
+
+func (k *K8s) prepareWorkerCloudConfig() (string, error) {
+	f, err := ioutil.TempFile("", "wcc")
+	if err != nil {
+		return "", err
+	}
+
+	t, err := template.New("worker cloud config").Parse(workerCloudConfig)
+	if err != nil {
+		return "", err
+	}
+
+	args := map[string]interface{}{
+		"MasterIP": k.masterIP,
+	}
+
+	err = t.Execute(f, args)
+	if err != nil {
+		return "", err
+	}
+
+	if err := f.Close(); err != nil {
+		return "", err
+	}
+
+	return f.Name(), nil
+}
+
+//go:generate embed file -var workerCloudConfig -source worker-cloud-config.yaml
+var workerCloudConfig = "#cloud-config\n\ncoreos:\n  flannel:\n    etcd_endpoints: https://{{.MasterIP}}:2379\n    etcd_cafile: /etc/kubernetes/ssl/ca.pem\n  units:\n    - name: flanneld.service\n      command: start\n    - name: kubelet.service\n      command: start\n      content: |\n        [Service]\n        ExecStart=/usr/bin/kubelet \\\n          --api-servers=https://{{.MasterIP}} \\\n          --tls-cert-file=/etc/kubernetes/ssl/worker.pem \\\n          --tls-private-key-file=/etc/kubernetes/ssl/worker-key.pem \\\n          --kubeconfig=/etc/kubernetes/worker-kubeconfig.yaml\n        Restart=always\n"